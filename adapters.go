@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"text/template"
+
+	"github.com/gomarkdown/markdown"
+	"github.com/gotify/plugin-api"
+)
+
+// FormatAdapter renders a Gotify message into the body and content type
+// expected by a particular downstream service.
+type FormatAdapter func(message plugin.Message) (body []byte, contentType string, err error)
+
+// formatAdapters maps a target's configured Format to the adapter that
+// knows how to shape the request body for that service.
+var formatAdapters = map[string]FormatAdapter{
+	"slack":           slackAdapter,
+	"discord":         discordAdapter,
+	"mattermost":      mattermostAdapter,
+	"rocketchat":      rocketchatAdapter,
+	"generic-json":    genericJSONAdapter,
+	"form-urlencoded": formURLEncodedAdapter,
+}
+
+func defaultAdapter() FormatAdapter {
+	return genericJSONAdapter
+}
+
+func slackAdapter(message plugin.Message) ([]byte, string, error) {
+	payload := struct {
+		Text string `json:"text"`
+	}{
+		Text: fmt.Sprintf("*%s*\n%s", message.Title, message.Message),
+	}
+	body, err := json.Marshal(payload)
+	return body, "application/json", err
+}
+
+func discordAdapter(message plugin.Message) ([]byte, string, error) {
+	payload := struct {
+		Content string `json:"content"`
+	}{
+		Content: fmt.Sprintf("**%s**\n%s", message.Title, message.Message),
+	}
+	body, err := json.Marshal(payload)
+	return body, "application/json", err
+}
+
+func mattermostAdapter(message plugin.Message) ([]byte, string, error) {
+	payload := struct {
+		Text string `json:"text"`
+	}{
+		Text: fmt.Sprintf("**%s**\n%s", message.Title, message.Message),
+	}
+	body, err := json.Marshal(payload)
+	return body, "application/json", err
+}
+
+func rocketchatAdapter(message plugin.Message) ([]byte, string, error) {
+	payload := struct {
+		Text string `json:"text"`
+	}{
+		Text: fmt.Sprintf("*%s*\n%s", message.Title, message.Message),
+	}
+	body, err := json.Marshal(payload)
+	return body, "application/json", err
+}
+
+func genericJSONAdapter(message plugin.Message) ([]byte, string, error) {
+	payload := &WebhookPost{
+		Username: message.Title,
+		Text:     message.Message,
+		Html:     string(markdown.ToHTML([]byte(message.Message), nil, nil)),
+	}
+	body, err := json.Marshal(payload)
+	return body, "application/json", err
+}
+
+func formURLEncodedAdapter(message plugin.Message) ([]byte, string, error) {
+	values := url.Values{}
+	values.Set("title", message.Title)
+	values.Set("message", message.Message)
+	values.Set("priority", fmt.Sprintf("%d", message.Priority))
+
+	return []byte(values.Encode()), "application/x-www-form-urlencoded", nil
+}
+
+// renderBody produces the request body for a target: a custom text/template
+// takes precedence when configured, otherwise the named format adapter is
+// used, falling back to genericJSONAdapter when Format is empty or unknown.
+func renderBody(target WebhookTarget, message plugin.Message) ([]byte, string, error) {
+	if target.BodyTemplate != "" {
+		body, err := renderBodyTemplate(target.BodyTemplate, message)
+		if err != nil {
+			return nil, "", err
+		}
+		return body, "application/json", nil
+	}
+
+	adapter, ok := formatAdapters[target.Format]
+	if !ok {
+		adapter = defaultAdapter()
+	}
+
+	return adapter(message)
+}
+
+func renderBodyTemplate(tmplText string, message plugin.Message) ([]byte, error) {
+	tmpl, err := parseBodyTemplate(tmplText)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, message); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func parseBodyTemplate(tmplText string) (*template.Template, error) {
+	return template.New("webhookBody").Parse(tmplText)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/go-playground/validator"
+	"github.com/gotify/plugin-api"
+)
+
+// Filter decides whether a Gotify message should be forwarded. A zero-value
+// Filter matches everything, so existing configs without a Filters section
+// keep their current behavior.
+//
+// There is deliberately no app-ID allow/deny list here: plugin.Message (the
+// type StartListener receives from the Gotify stream) only carries Message,
+// Title, Priority and Extras, so the application ID isn't available to
+// filter on with the current plugin-api version.
+type Filter struct {
+	MinPriority *int `json:"minPriority"`
+	MaxPriority *int `json:"maxPriority"`
+
+	TitlePattern   string `json:"titlePattern" validate:"omitempty,regex"`
+	MessagePattern string `json:"messagePattern" validate:"omitempty,regex"`
+
+	// ExtrasPath is a dotted path into plugin.Message.Extras, e.g.
+	// "client::notification.click.url". The first segment selects the
+	// top-level Extras key (which may itself contain "::"), the rest walk
+	// nested maps.
+	ExtrasPath    string `json:"extrasPath"`
+	ExtrasPattern string `json:"extrasPattern" validate:"omitempty,regex"`
+}
+
+// Match reports whether message passes every configured rule in f.
+func (f Filter) Match(message plugin.Message) bool {
+	if f.MinPriority != nil && message.Priority < *f.MinPriority {
+		return false
+	}
+
+	if f.MaxPriority != nil && message.Priority > *f.MaxPriority {
+		return false
+	}
+
+	if f.TitlePattern != "" && !matchesPattern(f.TitlePattern, message.Title) {
+		return false
+	}
+
+	if f.MessagePattern != "" && !matchesPattern(f.MessagePattern, message.Message) {
+		return false
+	}
+
+	if f.ExtrasPath != "" {
+		value, ok := extrasValueAt(message.Extras, f.ExtrasPath)
+		if !ok {
+			return false
+		}
+
+		if f.ExtrasPattern != "" && !matchesPattern(f.ExtrasPattern, fmt.Sprintf("%v", value)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func matchesPattern(pattern, value string) bool {
+	matched, err := regexp.MatchString(pattern, value)
+	return err == nil && matched
+}
+
+// extrasValueAt walks a dotted path into a Message's Extras map, e.g.
+// "client::notification.click.url" -> extras["client::notification"]["click"]["url"].
+func extrasValueAt(extras map[string]interface{}, path string) (interface{}, bool) {
+	parts := strings.Split(path, ".")
+
+	current, ok := extras[parts[0]]
+	if !ok {
+		return nil, false
+	}
+
+	for _, key := range parts[1:] {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+
+		current, ok = m[key]
+		if !ok {
+			return nil, false
+		}
+	}
+
+	return current, true
+}
+
+// validateRegexField is registered as the "regex" validator tag so
+// ValidateAndSetConfig rejects malformed filter patterns at configuration
+// time instead of failing silently on the first matched message.
+func validateRegexField(fl validator.FieldLevel) bool {
+	_, err := regexp.Compile(fl.Field().String())
+	return err == nil
+}
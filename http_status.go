@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// DeliveryError wraps a non-2xx HTTP response from a delivery attempt,
+// distinguishing transient failures (429, 5xx) that should be retried from
+// permanent ones (4xx other than 429) that shouldn't.
+type DeliveryError struct {
+	StatusCode int
+	Retryable  bool
+	RetryAfter time.Duration
+}
+
+func (e *DeliveryError) Error() string {
+	return fmt.Sprintf("unexpected status %d", e.StatusCode)
+}
+
+// classifyResponse turns an HTTP response into nil (2xx) or a *DeliveryError
+// describing whether the caller should retry, honoring Retry-After when the
+// remote sends one.
+func classifyResponse(res *http.Response) error {
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return nil
+	}
+
+	retryable := res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500
+
+	return &DeliveryError{
+		StatusCode: res.StatusCode,
+		Retryable:  retryable,
+		RetryAfter: parseRetryAfter(res.Header.Get("Retry-After")),
+	}
+}
+
+// parseRetryAfter accepts both the delay-seconds and HTTP-date forms of the
+// Retry-After header (RFC 7231 §7.1.3).
+func parseRetryAfter(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(header); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if when, err := http.ParseTime(header); err == nil {
+		if d := time.Until(when); d > 0 {
+			return d
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gotify/plugin-api"
+)
+
+// InboundRoute binds one HTTP path on the inbound listener to a parser and
+// the Gotify application token messages on that path should be posted as.
+type InboundRoute struct {
+	Path     string `json:"path" validate:"required"`
+	Parser   string `json:"parser" validate:"required,oneof=generic-json alertmanager"`
+	AppToken string `json:"appToken" validate:"required"`
+}
+
+// validateInboundRoutes rejects duplicate route paths at configuration time;
+// http.ServeMux.HandleFunc panics on a duplicate pattern, and StartInboundServer
+// runs unrecovered in its own goroutine, so this must be caught before Enable.
+func validateInboundRoutes(routes []InboundRoute) error {
+	seen := make(map[string]bool, len(routes))
+
+	for _, route := range routes {
+		if seen[route.Path] {
+			return errors.New("duplicate inbound route path: " + route.Path)
+		}
+		seen[route.Path] = true
+	}
+
+	return nil
+}
+
+// InboundParser turns a third-party webhook body into zero or more Gotify
+// messages, analogous to the outbound FormatAdapter.
+type InboundParser func(body []byte) ([]plugin.Message, error)
+
+var inboundParsers = map[string]InboundParser{
+	"generic-json": genericJSONInboundParser,
+	"alertmanager": alertmanagerInboundParser,
+}
+
+func genericJSONInboundParser(body []byte) ([]plugin.Message, error) {
+	var payload struct {
+		Title    string `json:"title"`
+		Message  string `json:"message"`
+		Priority int    `json:"priority"`
+	}
+
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+
+	return []plugin.Message{{
+		Title:    payload.Title,
+		Message:  payload.Message,
+		Priority: payload.Priority,
+	}}, nil
+}
+
+// alertmanagerInboundParser maps a Prometheus Alertmanager webhook payload
+// to one Gotify message per alert, using labels.severity for priority and
+// annotations.summary/description for the title/body.
+func alertmanagerInboundParser(body []byte) ([]plugin.Message, error) {
+	var payload struct {
+		Alerts []struct {
+			Labels      map[string]string `json:"labels"`
+			Annotations map[string]string `json:"annotations"`
+		} `json:"alerts"`
+	}
+
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+
+	messages := make([]plugin.Message, 0, len(payload.Alerts))
+
+	for _, alert := range payload.Alerts {
+		title := alert.Annotations["summary"]
+		if title == "" {
+			title = alert.Labels["alertname"]
+		}
+
+		message := alert.Annotations["description"]
+		if message == "" {
+			message = title
+		}
+
+		messages = append(messages, plugin.Message{
+			Title:    title,
+			Message:  message,
+			Priority: alertSeverityToPriority(alert.Labels["severity"]),
+		})
+	}
+
+	return messages, nil
+}
+
+func alertSeverityToPriority(severity string) int {
+	switch severity {
+	case "critical":
+		return 8
+	case "warning":
+		return 5
+	case "info", "none":
+		return 2
+	default:
+		return 5
+	}
+}
+
+// StartInboundServer runs the embedded HTTP listener that accepts inbound
+// webhooks and forwards them to the host Gotify server's REST API. It blocks
+// until ctx is cancelled, or returns the error early if the server fails to
+// listen or serve.
+func (c *WebhookerPlugin) StartInboundServer(ctx context.Context) error {
+	mux := http.NewServeMux()
+
+	for _, route := range c.config.InboundRoutes {
+		route := route
+
+		parser, ok := inboundParsers[route.Parser]
+		if !ok {
+			log.Printf("Unknown inbound parser %q for route %q, skipping", route.Parser, route.Path)
+			continue
+		}
+
+		mux.HandleFunc(route.Path, func(w http.ResponseWriter, r *http.Request) {
+			c.handleInbound(w, r, route, parser)
+		})
+	}
+
+	server := &http.Server{Addr: c.config.InboundBindAddress, Handler: mux}
+
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	log.Println("Inbound webhook listener started on", c.config.InboundBindAddress)
+
+	err := server.ListenAndServe()
+	if err != nil && err != http.ErrServerClosed {
+		log.Println("Inbound server error:", err)
+		return err
+	}
+
+	return nil
+}
+
+func (c *WebhookerPlugin) handleInbound(w http.ResponseWriter, r *http.Request, route InboundRoute, parser InboundParser) {
+	defer r.Body.Close()
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+
+	messages, err := parser(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	for _, message := range messages {
+		if err := c.postMessageToGotify(route.AppToken, message); err != nil {
+			log.Println("Failed to forward inbound message to Gotify:", err)
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// postMessageToGotify posts message to the host server's /message REST
+// endpoint, authenticated with appToken.
+func (c *WebhookerPlugin) postMessageToGotify(appToken string, message plugin.Message) error {
+	payload := struct {
+		Title    string                 `json:"title"`
+		Message  string                 `json:"message"`
+		Priority int                    `json:"priority"`
+		Extras   map[string]interface{} `json:"extras,omitempty"`
+	}{message.Title, message.Message, message.Priority, message.Extras}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", c.gotifyRestBase()+"/message?token="+appToken, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	return classifyResponse(res)
+}
+
+func (c *WebhookerPlugin) gotifyRestBase() string {
+	base := strings.Replace(c.config.HostServer, "wss://", "https://", 1)
+	base = strings.Replace(base, "ws://", "http://", 1)
+	return base
+}
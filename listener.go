@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/gotify/plugin-api"
+)
+
+// pongWait bounds how long we'll wait for a pong (or any other frame) before
+// considering the connection dead; it must comfortably exceed PingInterval.
+const pongWait = 70 * time.Second
+
+// StartListener keeps a Gotify stream connection alive for as long as ctx is
+// not cancelled, reconnecting with exponential backoff on any failure
+// instead of killing the process.
+func (c *WebhookerPlugin) StartListener(ctx context.Context, serverUrl string) {
+	attempts := 0
+
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		err := c.runListenerSession(ctx, serverUrl, func() { attempts = 0 })
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		attempts++
+		backoff := backoffDuration(attempts, time.Second, c.config.ReconnectMaxBackoff)
+		log.Printf("Websocket session ended (%v), reconnecting in %s", err, backoff)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+	}
+}
+
+// runListenerSession dials once, pumps incoming messages to DispatchMessage
+// and sends periodic pings, returning when the connection fails or ctx is
+// cancelled. onConnected is called once the dial succeeds, so the caller can
+// reset its reconnect backoff.
+func (c *WebhookerPlugin) runListenerSession(ctx context.Context, serverUrl string, onConnected func()) error {
+	ws, _, err := websocket.DefaultDialer.Dial(serverUrl, nil)
+	if err != nil {
+		return err
+	}
+	defer ws.Close()
+
+	log.Printf("Connected to %s", serverUrl)
+	onConnected()
+
+	ws.SetReadDeadline(time.Now().Add(pongWait))
+	ws.SetPongHandler(func(string) error {
+		ws.SetReadDeadline(time.Now().Add(pongWait))
+		return nil
+	})
+
+	readErr := make(chan error, 1)
+
+	go func() {
+		for {
+			_, message, err := ws.ReadMessage()
+
+			if err != nil {
+				readErr <- err
+				return
+			}
+
+			var incomingMsg plugin.Message
+			if err := json.Unmarshal(message, &incomingMsg); err != nil {
+				log.Println("Json parsing error: ", err)
+				continue
+			}
+
+			if !c.config.Filters.Match(incomingMsg) {
+				continue
+			}
+
+			c.DispatchMessage(incomingMsg)
+		}
+	}()
+
+	pingInterval := c.config.PingInterval
+	if pingInterval <= 0 {
+		pingInterval = 54 * time.Second
+	}
+
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+			return nil
+		case err := <-readErr:
+			return err
+		case <-ticker.C:
+			ws.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if err := ws.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return err
+			}
+		}
+	}
+}
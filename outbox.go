@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gotify/plugin-api"
+)
+
+// OutboxEntry is a single pending delivery: a rendered message bound to one
+// webhook target, waiting for its next delivery attempt.
+type OutboxEntry struct {
+	ID          string         `json:"id"`
+	Target      WebhookTarget  `json:"target"`
+	Message     plugin.Message `json:"message"`
+	Attempts    int            `json:"attempts"`
+	NextAttempt time.Time      `json:"nextAttempt"`
+}
+
+// Outbox is an in-memory, mutex-guarded queue of pending deliveries. Callers
+// are responsible for persisting Snapshot()/Restore() through the plugin's
+// StorageHandler so entries survive a disable/restart cycle.
+type Outbox struct {
+	mu      sync.Mutex
+	entries []OutboxEntry
+	nextID  int
+	maxSize int
+}
+
+// NewOutbox creates an Outbox that rejects new entries once it holds maxSize
+// of them. maxSize <= 0 means unbounded.
+func NewOutbox(maxSize int) *Outbox {
+	return &Outbox{maxSize: maxSize}
+}
+
+// Enqueue adds a new entry, or returns an error without adding it once the
+// queue already holds maxSize entries.
+func (o *Outbox) Enqueue(target WebhookTarget, message plugin.Message) (OutboxEntry, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.maxSize > 0 && len(o.entries) >= o.maxSize {
+		return OutboxEntry{}, errors.New("outbox queue is full")
+	}
+
+	o.nextID++
+	entry := OutboxEntry{
+		ID:          strconv.Itoa(o.nextID),
+		Target:      target,
+		Message:     message,
+		NextAttempt: time.Now(),
+	}
+	o.entries = append(o.entries, entry)
+	return entry, nil
+}
+
+// ClaimDue removes and returns the entries whose NextAttempt has arrived, so
+// each entry is handed to exactly one in-flight delivery attempt. A claimed
+// entry stays out of the queue until the caller requeues it with Requeue.
+func (o *Outbox) ClaimDue(now time.Time) []OutboxEntry {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	due := make([]OutboxEntry, 0, len(o.entries))
+	remaining := make([]OutboxEntry, 0, len(o.entries))
+
+	for _, entry := range o.entries {
+		if !entry.NextAttempt.After(now) {
+			due = append(due, entry)
+		} else {
+			remaining = append(remaining, entry)
+		}
+	}
+
+	o.entries = remaining
+
+	return due
+}
+
+// Requeue puts a previously claimed entry back on the queue, e.g. after a
+// failed delivery attempt that should be retried at entry.NextAttempt.
+func (o *Outbox) Requeue(entry OutboxEntry) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.entries = append(o.entries, entry)
+}
+
+func (o *Outbox) Snapshot() []OutboxEntry {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	snapshot := make([]OutboxEntry, len(o.entries))
+	copy(snapshot, o.entries)
+	return snapshot
+}
+
+// Restore replaces the queue with previously persisted entries, picking the
+// ID counter back up so new entries don't collide with restored ones.
+func (o *Outbox) Restore(entries []OutboxEntry) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.entries = entries
+
+	for _, entry := range entries {
+		if id, err := strconv.Atoi(entry.ID); err == nil && id > o.nextID {
+			o.nextID = id
+		}
+	}
+}
+
+// backoffDuration computes an exponential backoff with jitter for the given
+// attempt number, bounded by initial/max.
+func backoffDuration(attempts int, initial, max time.Duration) time.Duration {
+	if initial <= 0 {
+		initial = time.Second
+	}
+	if max <= 0 {
+		max = 5 * time.Minute
+	}
+
+	backoff := initial * time.Duration(uint(1)<<uint(attempts))
+	if backoff > max || backoff <= 0 {
+		backoff = max
+	}
+
+	jitter := time.Duration(rand.Int63n(int64(backoff)/2 + 1))
+
+	return backoff/2 + jitter
+}
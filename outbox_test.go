@@ -0,0 +1,59 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gotify/plugin-api"
+)
+
+// TestOutboxClaimDueIsExclusive guards against the regression fixed in
+// 7bc2037: ClaimDue must remove an entry from the queue as soon as it's
+// claimed, so a slow in-flight delivery can't be handed to a second worker
+// by the next poll.
+func TestOutboxClaimDueIsExclusive(t *testing.T) {
+	o := NewOutbox(0)
+	o.Enqueue(WebhookTarget{Name: "t"}, plugin.Message{})
+
+	var claims int32
+	var wg sync.WaitGroup
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if entries := o.ClaimDue(time.Now()); len(entries) > 0 {
+				atomic.AddInt32(&claims, int32(len(entries)))
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if claims != 1 {
+		t.Fatalf("expected exactly 1 claim across concurrent ClaimDue calls, got %d", claims)
+	}
+}
+
+// TestOutboxEnqueueRejectsWhenFull guards the QueueSize enforcement added
+// alongside this test: Enqueue must fail once the queue holds maxSize
+// entries instead of growing without bound.
+func TestOutboxEnqueueRejectsWhenFull(t *testing.T) {
+	o := NewOutbox(2)
+
+	if _, err := o.Enqueue(WebhookTarget{Name: "a"}, plugin.Message{}); err != nil {
+		t.Fatalf("unexpected error enqueuing 1st entry: %v", err)
+	}
+	if _, err := o.Enqueue(WebhookTarget{Name: "b"}, plugin.Message{}); err != nil {
+		t.Fatalf("unexpected error enqueuing 2nd entry: %v", err)
+	}
+	if _, err := o.Enqueue(WebhookTarget{Name: "c"}, plugin.Message{}); err == nil {
+		t.Fatal("expected an error enqueuing past maxSize, got nil")
+	}
+
+	if got := len(o.Snapshot()); got != 2 {
+		t.Fatalf("expected queue to stay at 2 entries, got %d", got)
+	}
+}
@@ -2,16 +2,15 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"log"
 	"net/http"
 	"os"
-	"os/signal"
 	"time"
 
 	"github.com/go-playground/validator"
-	"github.com/gomarkdown/markdown"
 	"github.com/gorilla/websocket"
 	"github.com/gotify/plugin-api"
 )
@@ -34,54 +33,256 @@ func GetGotifyPluginInfo() plugin.Info {
 	}
 }
 
+type WebhookTarget struct {
+	Name			string				`json:"name"`
+	Url				string				`json:"url" validate:"required,url"`
+	Method			string				`json:"method"`
+	Headers			map[string]string	`json:"headers"`
+	BodyTemplate	string				`json:"bodyTemplate"`
+	Format			string				`json:"format" validate:"omitempty,oneof=slack discord mattermost rocketchat generic-json form-urlencoded"`
+	Filters			Filter				`json:"filters"`
+
+	Secret				string	`json:"secret"`
+	SignatureHeader		string	`json:"signatureHeader"`
+	TimestampHeader		string	`json:"timestampHeader"`
+	SignaturePrefix		string	`json:"signaturePrefix"`
+
+	TLSCertFile			string	`json:"tlsCertFile"`
+	TLSKeyFile			string	`json:"tlsKeyFile"`
+	TLSCAFile			string	`json:"tlsCaFile"`
+	InsecureSkipVerify	bool	`json:"insecureSkipVerify"`
+}
+
 type Config struct {
-	WebhookUrl		string
 	HostServer		string
 	ClientToken		string
+	Webhooks		[]WebhookTarget	`validate:"dive"`
+	Filters			Filter			`json:"filters"`
+
+	MaxRetries		int
+	InitialBackoff	time.Duration
+	MaxBackoff		time.Duration
+	Concurrency		int
+	QueueSize		int
+	DeadLetterPath	string
+
+	PingInterval		time.Duration
+	ReconnectMaxBackoff	time.Duration
+
+	InboundEnabled		bool			`json:"inboundEnabled"`
+	InboundBindAddress	string			`json:"inboundBindAddress"`
+	InboundRoutes		[]InboundRoute	`json:"inboundRoutes" validate:"dive"`
 }
 
 type Storage struct {
-	WasEnabled bool `json:"wasEnabled"`
+	WasEnabled bool          `json:"wasEnabled"`
+	Outbox     []OutboxEntry `json:"outbox"`
 }
 
 type WebhookerPlugin struct {
 	enabled					bool
 	storageHandler	plugin.StorageHandler
 	config					*Config
+
+	outbox		*Outbox
+	outboxStop	chan struct{}
+
+	listenerCancel	context.CancelFunc
+	inboundCancel	context.CancelFunc
+}
+
+// DispatchMessage fans a single Gotify message out to every configured
+// webhook target whose Filters match it, by enqueuing one outbox entry per
+// such target. Delivery itself
+// happens on the worker pool started in Enable, with retries on failure.
+func (c *WebhookerPlugin) DispatchMessage(message plugin.Message) {
+	for _, target := range c.config.Webhooks {
+		if !target.Filters.Match(message) {
+			continue
+		}
+
+		if _, err := c.outbox.Enqueue(target, message); err != nil {
+			log.Printf("Dropping message for target %q: %v", target.Name, err)
+			continue
+		}
+	}
+
+	c.persistOutbox()
+}
+
+// runOutboxWorkers polls the outbox for due entries and attempts delivery on
+// a bounded pool of goroutines until outboxStop is closed. ClaimDue removes
+// each due entry from the queue before handing it to a goroutine, so a slow
+// delivery can't be picked up again by the next tick.
+func (c *WebhookerPlugin) runOutboxWorkers() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	sem := make(chan struct{}, c.concurrencyLimit())
+	stop := c.outboxStop
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			for _, entry := range c.outbox.ClaimDue(time.Now()) {
+				sem <- struct{}{}
+
+				go func(entry OutboxEntry) {
+					defer func() { <-sem }()
+					c.attemptDelivery(entry)
+				}(entry)
+			}
+		}
+	}
+}
+
+func (c *WebhookerPlugin) concurrencyLimit() int {
+	if c.config.Concurrency > 0 {
+		return c.config.Concurrency
+	}
+	return 4
+}
+
+// attemptDelivery sends a single claimed outbox entry. On success the entry
+// is gone for good. A retryable failure is requeued with exponential backoff
+// (or the remote's Retry-After); a non-retryable failure, or the failure that
+// brings the entry to MaxRetries attempts, drops the entry and appends it to
+// DeadLetterPath when one is configured.
+func (c *WebhookerPlugin) attemptDelivery(entry OutboxEntry) {
+	err := c.SendPostToWebhook(entry.Target, entry.Message)
+	if err == nil {
+		c.persistOutbox()
+		return
+	}
+
+	retryAfter := time.Duration(0)
+
+	var delivErr *DeliveryError
+	if errors.As(err, &delivErr) {
+		if !delivErr.Retryable {
+			log.Printf("Giving up on webhook %q: %v", entry.Target.Name, err)
+			c.deadLetter(entry, err)
+			c.persistOutbox()
+			return
+		}
+		retryAfter = delivErr.RetryAfter
+	}
+
+	attempts := entry.Attempts + 1
+	maxRetries := c.config.MaxRetries
+	if maxRetries <= 0 {
+		maxRetries = 5
+	}
+
+	if attempts >= maxRetries {
+		log.Printf("Giving up on webhook %q after %d attempts: %v", entry.Target.Name, attempts, err)
+		c.deadLetter(entry, err)
+		c.persistOutbox()
+		return
+	}
+
+	backoff := backoffDuration(attempts, c.config.InitialBackoff, c.config.MaxBackoff)
+	if retryAfter > 0 {
+		backoff = retryAfter
+	}
+	log.Printf("Retry %d/%d for webhook %q in %s: %v", attempts, maxRetries, entry.Target.Name, backoff, err)
+
+	entry.Attempts = attempts
+	entry.NextAttempt = time.Now().Add(backoff)
+	c.outbox.Requeue(entry)
+	c.persistOutbox()
+}
+
+func (c *WebhookerPlugin) deadLetter(entry OutboxEntry, cause error) {
+	if c.config.DeadLetterPath == "" {
+		return
+	}
+
+	record := struct {
+		Entry OutboxEntry `json:"entry"`
+		Error string      `json:"error"`
+		Time  time.Time   `json:"time"`
+	}{entry, cause.Error(), time.Now()}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		log.Println("Dead-letter marshal error:", err)
+		return
+	}
+
+	f, err := os.OpenFile(c.config.DeadLetterPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Println("Dead-letter write error:", err)
+		return
+	}
+	defer f.Close()
+
+	f.Write(append(data, '\n'))
 }
 
-func (c *WebhookerPlugin) SendPostToWebhook(webhookUrl string, message plugin.Message) (err error) {
-	webhookPost := &WebhookPost{
-		Username: message.Title,
-		Text: message.Message,
-		Html: string(markdown.ToHTML([]byte(message.Message), nil, nil)),
+// persistOutbox snapshots the in-memory queue into the plugin's storage so
+// pending deliveries survive a disable/restart cycle.
+func (c *WebhookerPlugin) persistOutbox() {
+	storage := new(Storage)
+	storageBytes, err := c.storageHandler.Load()
+	if err != nil {
+		log.Println("Outbox persist load error:", err)
+		return
 	}
 
-	body, err := json.Marshal(webhookPost)
+	json.Unmarshal(storageBytes, storage)
+	storage.Outbox = c.outbox.Snapshot()
+
+	storageBytes, err = json.Marshal(storage)
+	if err != nil {
+		log.Println("Outbox persist marshal error:", err)
+		return
+	}
 
-	log.Println("Sending: ", webhookPost)
+	c.storageHandler.Save(storageBytes)
+}
 
+func (c *WebhookerPlugin) SendPostToWebhook(target WebhookTarget, message plugin.Message) (err error) {
+	body, contentType, err := renderBody(target, message)
 	if err != nil {
 		return err
 	}
 
-	req, err := http.NewRequest("POST", webhookUrl, bytes.NewBuffer(body))
+	log.Println("Sending to", target.Name, ":", string(body))
+
+	method := target.Method
+	if method == "" {
+		method = "POST"
+	}
+
+	req, err := http.NewRequest(method, target.Url, bytes.NewBuffer(body))
 
 	if err != nil {
 		return err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Type", contentType)
 
-	client := &http.Client{}
-	res, err := client.Do(req)
+	for key, value := range target.Headers {
+		req.Header.Set(key, value)
+	}
+
+	signRequest(req, target, body)
+
+	client, err := httpClientFor(target)
 	if err != nil {
 		return err
 	}
 
-	res.Body.Close()
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
 
-	return
+	return classifyResponse(res)
 }
 
 func (c *WebhookerPlugin) TestSocket(url string) (err error) {
@@ -99,9 +300,23 @@ func (c *WebhookerPlugin) SetStorageHandler(h plugin.StorageHandler) {
 
 func (c *WebhookerPlugin) DefaultConfig() interface{} {
 	config := &Config{
-		WebhookUrl: "",
 		ClientToken: "",
 		HostServer: "ws://localhost:8080",
+		Webhooks: []WebhookTarget{},
+
+		MaxRetries: 5,
+		InitialBackoff: time.Second,
+		MaxBackoff: 5 * time.Minute,
+		Concurrency: 4,
+		QueueSize: 1000,
+		DeadLetterPath: "",
+
+		PingInterval: 54 * time.Second,
+		ReconnectMaxBackoff: 30 * time.Second,
+
+		InboundEnabled: false,
+		InboundBindAddress: ":8765",
+		InboundRoutes: []InboundRoute{},
 	}
 	return config
 }
@@ -110,6 +325,7 @@ func (c *WebhookerPlugin) ValidateAndSetConfig(conf interface{}) error {
     config := conf.(*Config)
 
 		v := validator.New()
+		v.RegisterValidation("regex", validateRegexField)
 		err := v.Struct(config)
 
     if err != nil {
@@ -117,6 +333,11 @@ func (c *WebhookerPlugin) ValidateAndSetConfig(conf interface{}) error {
         return errors.New("Validation error: " + err.Error())
     }
 
+		if err := validateInboundRoutes(config.InboundRoutes); err != nil {
+			log.Println("Validation error: ", err)
+			return errors.New("Validation error: " + err.Error())
+		}
+
     c.config = config
 
 		storage := new(Storage)
@@ -129,6 +350,10 @@ func (c *WebhookerPlugin) ValidateAndSetConfig(conf interface{}) error {
 		json.Unmarshal(storageBytes, storage)
 		c.enabled = storage.WasEnabled
 
+		outbox := NewOutbox(config.QueueSize)
+		outbox.Restore(storage.Outbox)
+		c.outbox = outbox
+
     return nil
 }
 
@@ -139,8 +364,8 @@ func (c *WebhookerPlugin) Enable() error {
 	if len (c.config.ClientToken) < 1 {
 			return errors.New("Please enter the client token")
 	}
-	if len (c.config.WebhookUrl) < 1 {
-		return errors.New("Please enter the correct webhook url")
+	if len (c.config.Webhooks) < 1 {
+		return errors.New("Please configure at least one webhook target")
 	}
 
 	serverUrl := c.config.HostServer + "/stream?token=" + c.config.ClientToken
@@ -153,7 +378,18 @@ func (c *WebhookerPlugin) Enable() error {
 
 	log.Println("Websocket url : ", serverUrl)
 
-	go c.StartListener(serverUrl)
+	listenerCtx, cancel := context.WithCancel(context.Background())
+	c.listenerCancel = cancel
+	go c.StartListener(listenerCtx, serverUrl)
+
+	c.outboxStop = make(chan struct{})
+	go c.runOutboxWorkers()
+
+	if c.config.InboundEnabled {
+		inboundCtx, inboundCancel := context.WithCancel(context.Background())
+		c.inboundCancel = inboundCancel
+		go c.StartInboundServer(inboundCtx)
+	}
 
 	c.enabled = true
 	log.Println("Webhooker plugin enabled")
@@ -165,6 +401,7 @@ func (c *WebhookerPlugin) Enable() error {
 		return err
 	}
 
+	json.Unmarshal(storageBytes, storage)
 	storage.WasEnabled = true
 	storageBytes, _ = json.Marshal(storage)
 	c.storageHandler.Save(storageBytes)
@@ -176,6 +413,21 @@ func (c *WebhookerPlugin) Disable() error {
 	c.enabled = false
 	log.Println("Webhooker plugin disabled")
 
+	if c.listenerCancel != nil {
+		c.listenerCancel()
+		c.listenerCancel = nil
+	}
+
+	if c.inboundCancel != nil {
+		c.inboundCancel()
+		c.inboundCancel = nil
+	}
+
+	if c.outboxStop != nil {
+		close(c.outboxStop)
+		c.outboxStop = nil
+	}
+
 	storage := new(Storage)
 	storageBytes, err := c.storageHandler.Load()
 
@@ -183,6 +435,8 @@ func (c *WebhookerPlugin) Disable() error {
 		return err
 	}
 
+	json.Unmarshal(storageBytes, storage)
+
 	storage.WasEnabled = false
 	storageBytes, _ = json.Marshal(storage)
 	c.storageHandler.Save(storageBytes)
@@ -190,83 +444,6 @@ func (c *WebhookerPlugin) Disable() error {
 	return nil
 }
 
-func (c *WebhookerPlugin) StartListener(serverUrl string) (err error) {
-	interrupt := make(chan os.Signal, 1)
-	signal.Notify(interrupt, os.Interrupt)
-
-	ws, _, err := websocket.DefaultDialer.Dial(serverUrl, nil)
-
-	if err != nil {
-		log.Fatal("Websocket error: ", err)
-
-		return err
-	}
-
-	log.Printf("Connected to %s", serverUrl)
-
-	defer ws.Close()
-
-	done := make(chan struct {})
-
-	incomingMsg := plugin.Message{}
-
-	go func() {
-		defer close(done)
-
-		for {
-			_, message, err := ws.ReadMessage()
-
-			if err != nil {
-				log.Fatal("Websocket read message error: ", err)
-				return
-			}
-
-			if err := json.Unmarshal(message, &incomingMsg); err != nil {
-				log.Fatal("Json parsing error: ", err)
-			}
-
-			err = c.SendPostToWebhook(c.config.WebhookUrl, incomingMsg)
-
-			if err != nil {
-				log.Printf("POST error: %v", err)
-			}
-		}
-	}()
-
-	ticker := time.NewTicker(time.Second)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <- done:
-			return
-		case t := <- ticker.C:
-			err := ws.WriteMessage(websocket.TextMessage, []byte(t.String()))
-
-			if err != nil {
-				log.Println("Websocket write error: ", err)
-
-				return err
-			}
-		case <- interrupt:
-			log.Println("Interrupt received")
-
-			err := ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
-
-			if err != nil {
-				log.Println("Websocket close error: ", err)
-				return err
-			}
-
-			select {
-			case <- done:
-			case <- time.After(time.Second):
-			}
-			return err
-		}
-	}
-}
-
 func NewGotifyPluginInstance(ctx plugin.UserContext) plugin.Plugin {
 	return &WebhookerPlugin{}
 }
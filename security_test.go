@@ -0,0 +1,76 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"testing"
+)
+
+func TestSignRequestSetsSignatureOverTimestampAndBody(t *testing.T) {
+	target := WebhookTarget{Secret: "s3cr3t"}
+	body := []byte(`{"hello":"world"}`)
+
+	req, err := http.NewRequest("POST", "http://example.invalid", nil)
+	if err != nil {
+		t.Fatalf("unexpected error building request: %v", err)
+	}
+
+	signRequest(req, target, body)
+
+	timestamp := req.Header.Get("X-Webhooker-Timestamp")
+	if timestamp == "" {
+		t.Fatal("expected X-Webhooker-Timestamp header to be set")
+	}
+
+	mac := hmac.New(sha256.New, []byte(target.Secret))
+	mac.Write([]byte(timestamp + "." + string(body)))
+	want := hex.EncodeToString(mac.Sum(nil))
+
+	if got := req.Header.Get("X-Webhooker-Signature"); got != want {
+		t.Fatalf("signature mismatch: got %q, want %q", got, want)
+	}
+}
+
+func TestSignRequestUsesCustomHeadersAndPrefix(t *testing.T) {
+	target := WebhookTarget{
+		Secret:          "s3cr3t",
+		SignatureHeader: "X-Custom-Signature",
+		TimestampHeader: "X-Custom-Timestamp",
+		SignaturePrefix: "sha256=",
+	}
+	body := []byte("payload")
+
+	req, err := http.NewRequest("POST", "http://example.invalid", nil)
+	if err != nil {
+		t.Fatalf("unexpected error building request: %v", err)
+	}
+
+	signRequest(req, target, body)
+
+	if req.Header.Get("X-Webhooker-Signature") != "" || req.Header.Get("X-Webhooker-Timestamp") != "" {
+		t.Fatal("expected default headers to be unset when custom ones are configured")
+	}
+
+	got := req.Header.Get("X-Custom-Signature")
+	if got == "" {
+		t.Fatal("expected X-Custom-Signature header to be set")
+	}
+	if got[:len(target.SignaturePrefix)] != target.SignaturePrefix {
+		t.Fatalf("expected signature to start with prefix %q, got %q", target.SignaturePrefix, got)
+	}
+}
+
+func TestSignRequestNoopWithoutSecret(t *testing.T) {
+	req, err := http.NewRequest("POST", "http://example.invalid", nil)
+	if err != nil {
+		t.Fatalf("unexpected error building request: %v", err)
+	}
+
+	signRequest(req, WebhookTarget{}, []byte("payload"))
+
+	if req.Header.Get("X-Webhooker-Signature") != "" {
+		t.Fatal("expected no signature header when target.Secret is unset")
+	}
+}